@@ -0,0 +1,145 @@
+// Copyright 2019 The Go Cloud Development Kit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package awsmysql provides connections to managed MySQL database instances
+// on Amazon RDS and Aurora.
+//
+// URLs
+//
+// For mysql.Open, awsmysql registers for the scheme "awsmysql".
+// The URL host:port holds the RDS instance endpoint, and the URL path
+// holds the database name: "awsmysql://host:3306/dbname". To connect over
+// TLS against the RDS CA bundle, supply it via the "sslrootcert" query
+// parameter, as with the parent mysql package.
+//
+// If the "iam=true" query parameter is set, the URL's password (if any)
+// is ignored; instead, an RDS IAM authentication token is generated using
+// the AWS SDK's default credential chain and used as the password for
+// every new connection, since such tokens are valid for only 15 minutes.
+// Since that token is sent to the server the same way a plaintext password
+// would be, iam=true requires TLS to already be configured via sslmode/
+// sslrootcert (or tls); OpenMySQLURL returns an error otherwise rather than
+// sending the token in cleartext.
+package awsmysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/rds/rdsutils"
+	gomysql "github.com/go-sql-driver/mysql"
+
+	"github.com/google/go-cloud/mysql"
+)
+
+// Scheme is the URL scheme awsmysql registers its URLOpener under on
+// mysql.DefaultURLMux.
+const Scheme = "awsmysql"
+
+func init() {
+	mysql.DefaultURLMux().RegisterMySQL(Scheme, new(URLOpener))
+}
+
+// URLOpener opens RDS/Aurora MySQL URLs like "awsmysql://host:3306/dbname".
+type URLOpener struct {
+	// Session, if set, is used to sign IAM authentication tokens. If nil, a
+	// Session is created from the environment's default AWS configuration.
+	Session *session.Session
+}
+
+// OpenMySQLURL opens an RDS or Aurora MySQL database, with support for
+// IAM-based database authentication. TLS is used only when the URL
+// configures it; iam=true requires it.
+func (o *URLOpener) OpenMySQLURL(ctx context.Context, u *url.URL) (*sql.DB, error) {
+	cfg, err := mysql.ConfigFromURL(u)
+	if err != nil {
+		return nil, fmt.Errorf("awsmysql: open URL %q: %v", u, err)
+	}
+
+	useIAM := false
+	if v := u.Query().Get("iam"); v != "" {
+		useIAM, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("awsmysql: open URL %q: invalid iam parameter %q: %v", u, v, err)
+		}
+	}
+	delete(cfg.Params, "iam")
+	cfg.AllowCleartextPasswords = useIAM
+	if useIAM && cfg.TLSConfig == "" {
+		return nil, fmt.Errorf("awsmysql: open URL %q: iam=true sends the IAM auth token as a cleartext password and requires TLS; supply sslmode/sslrootcert (or tls) query parameters", u)
+	}
+
+	if !useIAM {
+		return sql.OpenDB(dsnConnector{dsn: cfg.FormatDSN()}), nil
+	}
+
+	sess := o.Session
+	if sess == nil {
+		sess, err = session.NewSession()
+		if err != nil {
+			return nil, fmt.Errorf("awsmysql: open URL %q: creating AWS session: %v", u, err)
+		}
+	}
+	return sql.OpenDB(&iamConnector{cfg: cfg, sess: sess}), nil
+}
+
+// dsnConnector opens connections using a pre-formatted DSN, the same way
+// the parent mysql package's own connector does.
+type dsnConnector struct {
+	dsn string
+}
+
+func (c dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.Driver().Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return gomysql.MySQLDriver{}
+}
+
+// iamConnector implements driver.Connector. It mints a fresh RDS IAM
+// authentication token to use as the database password on every new
+// connection, since such tokens expire after 15 minutes.
+type iamConnector struct {
+	cfg  *gomysql.Config
+	sess *session.Session
+}
+
+func (c *iamConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	if c.sess.Config.Region == nil || *c.sess.Config.Region == "" {
+		return nil, fmt.Errorf("awsmysql: no AWS region configured; set URLOpener.Session with a Region or the AWS_REGION environment variable")
+	}
+	region := *c.sess.Config.Region
+	creds := c.sess.Config.Credentials
+	tok, err := rdsutils.BuildAuthToken(c.cfg.Addr, region, c.cfg.User, creds)
+	if err != nil {
+		return nil, fmt.Errorf("awsmysql: generating IAM auth token: %v", err)
+	}
+	cfg := *c.cfg
+	cfg.Passwd = tok
+	connector, err := gomysql.MySQLDriver{}.OpenConnector(cfg.FormatDSN())
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+func (c *iamConnector) Driver() driver.Driver {
+	return gomysql.MySQLDriver{}
+}
@@ -0,0 +1,144 @@
+// Copyright 2019 The Go Cloud Development Kit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package azuremysql provides connections to managed MySQL database
+// instances on Azure Database for MySQL.
+//
+// URLs
+//
+// For mysql.Open, azuremysql registers for the scheme "azuremysql".
+// The URL host:port holds the server's fully qualified domain name, and
+// the URL path holds the database name: "azuremysql://host:3306/dbname".
+// To connect over TLS against the Azure CA bundle, supply it via the
+// "sslrootcert" query parameter, as with the parent mysql package.
+//
+// If the "iam=true" query parameter is set, the URL's password (if any)
+// is ignored; instead, an Azure AD access token is fetched using the
+// environment's default Azure credentials and used as the password for
+// every new connection, since such tokens expire within the hour. Since
+// that token is sent to the server the same way a plaintext password would
+// be, iam=true requires TLS to already be configured via sslmode/
+// sslrootcert (or tls); OpenMySQLURL returns an error otherwise rather than
+// sending the token in cleartext.
+package azuremysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	gomysql "github.com/go-sql-driver/mysql"
+
+	"github.com/google/go-cloud/mysql"
+)
+
+// Scheme is the URL scheme azuremysql registers its URLOpener under on
+// mysql.DefaultURLMux.
+const Scheme = "azuremysql"
+
+// aadAuthScope is the OAuth2 scope required to mint an Azure Database for
+// MySQL access token.
+const aadAuthScope = "https://ossrdbms-aad.database.windows.net/.default"
+
+func init() {
+	mysql.DefaultURLMux().RegisterMySQL(Scheme, new(URLOpener))
+}
+
+// URLOpener opens Azure Database for MySQL URLs like
+// "azuremysql://host:3306/dbname".
+type URLOpener struct {
+	// Credential, if set, is used to fetch Azure AD access tokens. If nil,
+	// azidentity.NewDefaultAzureCredential is used.
+	Credential *azidentity.DefaultAzureCredential
+}
+
+// OpenMySQLURL opens an Azure Database for MySQL database, with support for
+// Azure AD-based database authentication.
+func (o *URLOpener) OpenMySQLURL(ctx context.Context, u *url.URL) (*sql.DB, error) {
+	cfg, err := mysql.ConfigFromURL(u)
+	if err != nil {
+		return nil, fmt.Errorf("azuremysql: open URL %q: %v", u, err)
+	}
+
+	useIAM := false
+	if v := u.Query().Get("iam"); v != "" {
+		useIAM, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("azuremysql: open URL %q: invalid iam parameter %q: %v", u, v, err)
+		}
+	}
+	delete(cfg.Params, "iam")
+	cfg.AllowCleartextPasswords = useIAM
+	if useIAM && cfg.TLSConfig == "" {
+		return nil, fmt.Errorf("azuremysql: open URL %q: iam=true sends the Azure AD token as a cleartext password and requires TLS; supply sslmode/sslrootcert (or tls) query parameters", u)
+	}
+
+	if !useIAM {
+		return sql.OpenDB(dsnConnector{dsn: cfg.FormatDSN()}), nil
+	}
+
+	cred := o.Credential
+	if cred == nil {
+		cred, err = azidentity.NewDefaultAzureCredential(nil)
+		if err != nil {
+			return nil, fmt.Errorf("azuremysql: open URL %q: creating Azure credential: %v", u, err)
+		}
+	}
+	return sql.OpenDB(&iamConnector{cfg: cfg, cred: cred}), nil
+}
+
+// dsnConnector opens connections using a pre-formatted DSN, the same way
+// the parent mysql package's own connector does.
+type dsnConnector struct {
+	dsn string
+}
+
+func (c dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.Driver().Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return gomysql.MySQLDriver{}
+}
+
+// iamConnector implements driver.Connector. It mints a fresh Azure AD
+// access token to use as the database password on every new connection,
+// since such tokens expire within the hour.
+type iamConnector struct {
+	cfg  *gomysql.Config
+	cred *azidentity.DefaultAzureCredential
+}
+
+func (c *iamConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	tok, err := c.cred.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{aadAuthScope}})
+	if err != nil {
+		return nil, fmt.Errorf("azuremysql: fetching Azure AD token: %v", err)
+	}
+	cfg := *c.cfg
+	cfg.Passwd = tok.Token
+	connector, err := gomysql.MySQLDriver{}.OpenConnector(cfg.FormatDSN())
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+func (c *iamConnector) Driver() driver.Driver {
+	return gomysql.MySQLDriver{}
+}
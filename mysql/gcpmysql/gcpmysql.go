@@ -0,0 +1,250 @@
+// Copyright 2019 The Go Cloud Development Kit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gcpmysql provides connections to managed MySQL database instances
+// on Google Cloud SQL.
+//
+// URLs
+//
+// For mysql.Open, gcpmysql registers for the scheme "gcpmysql".
+// The URL host holds the GCP project ID, and the URL path holds the
+// region, Cloud SQL instance name and database name, separated by
+// slashes: "gcpmysql://project/region/instance/dbname". Connections are
+// dialed through the Cloud SQL Proxy, so no IP allowlisting is required.
+//
+// If the "iam=true" query parameter is set, the URL's password (if any)
+// is ignored; instead, a short-lived IAM access token is fetched from the
+// environment's Application Default Credentials and used as the password
+// for every new connection, since such tokens expire quickly.
+package gcpmysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/cloudsqlconn"
+	gomysql "github.com/go-sql-driver/mysql"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+
+	"github.com/google/go-cloud/mysql"
+)
+
+// Scheme is the URL scheme gcpmysql registers its URLOpener under on
+// mysql.DefaultURLMux.
+const Scheme = "gcpmysql"
+
+// iamAuthScope is the OAuth2 scope required to mint a Cloud SQL IAM
+// authentication token.
+const iamAuthScope = "https://www.googleapis.com/auth/sqlservice.admin"
+
+func init() {
+	mysql.DefaultURLMux().RegisterMySQL(Scheme, new(URLOpener))
+}
+
+// URLOpener opens Cloud SQL URLs like "gcpmysql://project/region/instance/dbname".
+//
+// A URLOpener is meant to be constructed once (typically via init,
+// registering it on mysql.DefaultURLMux) and reused for the life of the
+// process, since it caches one cloudsqlconn.Dialer per (Cloud SQL instance,
+// whether IAM auth is in use) combination it has been asked to open, and
+// reuses that dialer across Open calls instead of creating a new one (and
+// its background certificate/token refresh goroutines) every time. Call
+// Close to shut down those dialers.
+type URLOpener struct {
+	// DialerOpts, if set, is passed to cloudsqlconn.NewDialer when
+	// constructing the Cloud SQL Proxy dialer used for every connection
+	// opened through this URLOpener.
+	DialerOpts []cloudsqlconn.Option
+
+	mu      sync.Mutex
+	dialers map[dialerKey]*cloudsqlconn.Dialer
+}
+
+// dialerKey identifies a cached cloudsqlconn.Dialer. A dialer built with
+// cloudsqlconn.WithIAMAuthN() is not interchangeable with one built without
+// it, so the two must never share a cache entry (or a registered network
+// name) even for the same instance.
+type dialerKey struct {
+	instance string
+	useIAM   bool
+}
+
+// netName returns the go-sql-driver/mysql custom network name used to dial
+// this key's instance.
+func (k dialerKey) netName() string {
+	if k.useIAM {
+		return "cloudsqlconn-iam-" + k.instance
+	}
+	return "cloudsqlconn-" + k.instance
+}
+
+// OpenMySQLURL opens a Cloud SQL MySQL database dialed through the Cloud SQL
+// Proxy, with support for IAM-based database authentication.
+func (o *URLOpener) OpenMySQLURL(ctx context.Context, u *url.URL) (*sql.DB, error) {
+	instance, dbName, err := instanceAndDBFromURL(u)
+	if err != nil {
+		return nil, fmt.Errorf("gcpmysql: open URL %q: %v", u, err)
+	}
+
+	useIAM := false
+	if v := u.Query().Get("iam"); v != "" {
+		useIAM, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("gcpmysql: open URL %q: invalid iam parameter %q: %v", u, v, err)
+		}
+	}
+
+	netName, err := o.netNameForInstance(ctx, instance, useIAM)
+	if err != nil {
+		return nil, fmt.Errorf("gcpmysql: open URL %q: %v", u, err)
+	}
+
+	cfg, err := mysql.ConfigFromURL(u)
+	if err != nil {
+		return nil, fmt.Errorf("gcpmysql: open URL %q: %v", u, err)
+	}
+	cfg.Net = netName
+	cfg.Addr = instance
+	cfg.DBName = dbName
+	cfg.TLSConfig = "" // TLS is handled by the Cloud SQL Proxy connection itself.
+	cfg.AllowCleartextPasswords = useIAM
+	delete(cfg.Params, "iam")
+
+	if !useIAM {
+		return sql.OpenDB(dsnConnector{dsn: cfg.FormatDSN()}), nil
+	}
+
+	ts, err := google.DefaultTokenSource(ctx, iamAuthScope)
+	if err != nil {
+		return nil, fmt.Errorf("gcpmysql: open URL %q: fetching IAM credentials: %v", u, err)
+	}
+	return sql.OpenDB(&iamConnector{cfg: cfg, tokenSource: ts}), nil
+}
+
+// netNameForInstance returns the go-sql-driver/mysql custom network name
+// registered for dialing the given Cloud SQL instance through the Cloud SQL
+// Proxy, creating and caching a cloudsqlconn.Dialer for that (instance,
+// useIAM) combination the first time it is asked for. The same dialer (and
+// network name) is reused for every later Open call made with the same
+// instance and useIAM, so that the dialer's background certificate/token
+// refresh goroutines aren't leaked on every call; see Close to shut them
+// down. A later call for the same instance with a different useIAM gets its
+// own dialer and network name, since a dialer built with
+// cloudsqlconn.WithIAMAuthN() cannot be reused for non-IAM connections or
+// vice versa.
+func (o *URLOpener) netNameForInstance(ctx context.Context, instance string, useIAM bool) (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	key := dialerKey{instance: instance, useIAM: useIAM}
+	netName := key.netName()
+	if _, ok := o.dialers[key]; ok {
+		return netName, nil
+	}
+
+	dialerOpts := o.DialerOpts
+	if useIAM {
+		dialerOpts = append(dialerOpts, cloudsqlconn.WithIAMAuthN())
+	}
+	dialer, err := cloudsqlconn.NewDialer(ctx, dialerOpts...)
+	if err != nil {
+		return "", fmt.Errorf("creating Cloud SQL Proxy dialer: %v", err)
+	}
+	if err := gomysql.RegisterDialContext(netName, func(ctx context.Context, addr string) (net.Conn, error) {
+		return dialer.Dial(ctx, instance)
+	}); err != nil {
+		return "", err
+	}
+	if o.dialers == nil {
+		o.dialers = map[dialerKey]*cloudsqlconn.Dialer{}
+	}
+	o.dialers[key] = dialer
+	return netName, nil
+}
+
+// Close closes every cloudsqlconn.Dialer this URLOpener has created, along
+// with their background certificate/token refresh goroutines. It should be
+// called once the URLOpener will no longer be used to open connections.
+func (o *URLOpener) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var firstErr error
+	for _, d := range o.dialers {
+		if err := d.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	o.dialers = nil
+	return firstErr
+}
+
+// instanceAndDBFromURL parses a URL of the form
+// "gcpmysql://project/region/instance/dbname" into a Cloud SQL Proxy
+// instance connection name ("project:region:instance") and database name.
+func instanceAndDBFromURL(u *url.URL) (instance, dbName string, err error) {
+	project := u.Host
+	parts := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
+	if project == "" || len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", "", fmt.Errorf("URL must be of the form \"gcpmysql://project/region/instance/dbname\", got %q", u)
+	}
+	return fmt.Sprintf("%s:%s:%s", project, parts[0], parts[1]), parts[2], nil
+}
+
+// dsnConnector opens connections using a pre-formatted DSN, the same way
+// the parent mysql package's own connector does.
+type dsnConnector struct {
+	dsn string
+}
+
+func (c dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.Driver().Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return gomysql.MySQLDriver{}
+}
+
+// iamConnector implements driver.Connector. It mints a fresh IAM access
+// token to use as the database password on every new connection, since IAM
+// tokens are short-lived and must not be baked into a static DSN.
+type iamConnector struct {
+	cfg         *gomysql.Config
+	tokenSource oauth2.TokenSource
+}
+
+func (c *iamConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	tok, err := c.tokenSource.Token()
+	if err != nil {
+		return nil, fmt.Errorf("gcpmysql: refreshing IAM token: %v", err)
+	}
+	cfg := *c.cfg
+	cfg.Passwd = tok.AccessToken
+	connector, err := gomysql.MySQLDriver{}.OpenConnector(cfg.FormatDSN())
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+func (c *iamConnector) Driver() driver.Driver {
+	return gomysql.MySQLDriver{}
+}
@@ -17,14 +17,22 @@ package mysql
 
 import (
 	"context"
+	"crypto/tls"
 	"database/sql"
 	"database/sql/driver"
 	"fmt"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-sql-driver/mysql"
 
 	"contrib.go.opencensus.io/integrations/ocsql"
+	"go.opencensus.io/trace"
+
+	"github.com/google/go-cloud/requestlog"
 )
 
 // Scheme is the URL scheme this package registers its URLOpener under on
@@ -37,24 +45,184 @@ func init() {
 
 // URLOpener opens URLs like "mysql://" by using the underlying MySQL driver.
 // See https://godoc.org/github.com/lib/pq#hdr-Connection_String_Parameters for details.
-type URLOpener struct{}
+type URLOpener struct {
+	// TLSConfig, if non-nil, is registered with the underlying MySQL driver
+	// and used for connections opened through this URLOpener, unless the URL
+	// itself requests TLS settings via the sslmode/sslrootcert/sslcert/sslkey
+	// query parameters, which take precedence.
+	TLSConfig *tls.Config
+
+	// Logger, if set, receives a requestlog.Entry for every connection
+	// dialed through this URLOpener, so operators can ship access logs
+	// without redefining the OpenCensus stats pipeline.
+	Logger requestlog.Logger
+}
 
 // OpenMySQLURL opens a new database connection wrapped with OpenCensus instrumentation.
-func (*URLOpener) OpenMySQLURL(ctx context.Context, u *url.URL) (*sql.DB, error) {
-	db, err := openWithUrl(u)
-	return db, err
+func (o *URLOpener) OpenMySQLURL(ctx context.Context, u *url.URL) (*sql.DB, error) {
+	cfg, err := ConfigFromURL(u)
+	if err != nil {
+		return nil, fmt.Errorf("mysql: open URL %q: %v", u, err)
+	}
+	if cfg.TLSConfig == "" && o.TLSConfig != nil {
+		name, err := registerTLSConfig(o.TLSConfig)
+		if err != nil {
+			return nil, fmt.Errorf("mysql: open URL %q: %v", u, err)
+		}
+		cfg.TLSConfig = name
+	}
+	return sql.OpenDB(connector{dsn: cfg.FormatDSN(), resource: cfg.Addr + "/" + cfg.DBName, logger: o.Logger}), nil
+}
+
+// ConfigFromURL translates a URL into a *mysql.Config usable to build a DSN
+// via Config.FormatDSN. The URL's user info, host and path map onto the
+// connection's user, password, address and database name; the following
+// query parameters map onto their corresponding Config fields:
+// collation, loc, parseTime, timeout, readTimeout, writeTimeout,
+// maxAllowedPacket, allowNativePasswords and tls (Config.TLSConfig).
+// The sslmode, sslrootcert, sslcert and sslkey parameters, when no tls
+// parameter is given, build a TLS config that is registered with the driver
+// and named in Config.TLSConfig; they are not passed on to the DSN.
+//
+// Any other query parameters, charset among them, are passed through as-is
+// via Config.Params.
+func ConfigFromURL(u *url.URL) (*mysql.Config, error) {
+	cfg := mysql.NewConfig()
+	cfg.Net = "tcp"
+	cfg.Addr = u.Host
+	cfg.DBName = strings.TrimPrefix(u.Path, "/")
+
+	if u.User != nil {
+		cfg.User = u.User.Username()
+		cfg.Passwd, _ = u.User.Password()
+	}
+
+	q := u.Query()
+	sslMode := q.Get("sslmode")
+	sslRootCert := q.Get("sslrootcert")
+	sslCert := q.Get("sslcert")
+	sslKey := q.Get("sslkey")
+	delete(q, "sslmode")
+	delete(q, "sslrootcert")
+	delete(q, "sslcert")
+	delete(q, "sslkey")
+
+	for param, values := range q {
+		if len(values) == 0 {
+			continue
+		}
+		v := values[0]
+		var err error
+		switch param {
+		case "charset":
+			cfg.Params = addParam(cfg.Params, "charset", v)
+		case "collation":
+			cfg.Collation = v
+		case "loc":
+			cfg.Loc, err = time.LoadLocation(v)
+		case "parseTime":
+			cfg.ParseTime, err = strconv.ParseBool(v)
+		case "allowNativePasswords":
+			cfg.AllowNativePasswords, err = strconv.ParseBool(v)
+		case "maxAllowedPacket":
+			cfg.MaxAllowedPacket, err = strconv.Atoi(v)
+		case "timeout":
+			cfg.Timeout, err = time.ParseDuration(v)
+		case "readTimeout":
+			cfg.ReadTimeout, err = time.ParseDuration(v)
+		case "writeTimeout":
+			cfg.WriteTimeout, err = time.ParseDuration(v)
+		case "tls":
+			cfg.TLSConfig = v
+		default:
+			cfg.Params = addParam(cfg.Params, param, v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s parameter %q: %v", param, v, err)
+		}
+	}
+
+	if cfg.TLSConfig == "" && (sslMode != "" || sslRootCert != "" || sslCert != "" || sslKey != "") {
+		name, err := registeredSSLTLSConfigName(cfg.Addr, sslMode, sslRootCert, sslCert, sslKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ssl parameters: %v", err)
+		}
+		cfg.TLSConfig = name
+	}
+	return cfg, nil
 }
 
-func openWithUrl(url *url.URL) (*sql.DB, error) {
-	return sql.OpenDB(connector{dsn: url.String()}), nil
+// sslTLSConfigNames caches the registered MySQL driver TLS config name for a
+// given combination of sslmode/sslrootcert/sslcert/sslkey URL parameters, so
+// that calling ConfigFromURL repeatedly for the same URL (as happens on
+// every Open call) registers at most one *tls.Config per distinct parameter
+// combination instead of leaking a new one into the driver's process-global
+// TLS config registry every time.
+var (
+	sslTLSConfigNamesMu sync.Mutex
+	sslTLSConfigNames   = map[string]string{}
+)
+
+// registeredSSLTLSConfigName returns the MySQL driver TLS config name
+// registered for the given sslmode/sslrootcert/sslcert/sslkey parameters,
+// building and registering the *tls.Config the first time it sees a given
+// combination and reusing the same name thereafter.
+func registeredSSLTLSConfigName(addr, sslMode, sslRootCert, sslCert, sslKey string) (string, error) {
+	key := strings.Join([]string{addr, sslMode, sslRootCert, sslCert, sslKey}, "\x00")
+
+	sslTLSConfigNamesMu.Lock()
+	defer sslTLSConfigNamesMu.Unlock()
+	if name, ok := sslTLSConfigNames[key]; ok {
+		return name, nil
+	}
+	tlsCfg, err := tlsConfigFromSSLParams(addr, sslMode, sslRootCert, sslCert, sslKey)
+	if err != nil {
+		return "", err
+	}
+	name, err := generateTLSConfigName()
+	if err != nil {
+		return "", err
+	}
+	if err := mysql.RegisterTLSConfig(name, tlsCfg); err != nil {
+		return "", fmt.Errorf("registering tls config: %v", err)
+	}
+	sslTLSConfigNames[key] = name
+	return name, nil
+}
+
+func addParam(params map[string]string, key, value string) map[string]string {
+	if params == nil {
+		params = map[string]string{}
+	}
+	params[key] = value
+	return params
 }
 
 type connector struct {
-	dsn string
+	dsn      string
+	resource string
+	logger   requestlog.Logger
 }
 
 func (c connector) Connect(ctx context.Context) (driver.Conn, error) {
-	return c.Driver().Open(c.dsn)
+	start := time.Now()
+	conn, err := c.Driver().Open(c.dsn)
+	if c.logger != nil {
+		entry := &requestlog.Entry{
+			ReceivedTime: start,
+			Operation:    "mysql.Connect",
+			Resource:     c.resource,
+			Latency:      time.Since(start),
+			Error:        err,
+		}
+		if span := trace.FromContext(ctx); span != nil {
+			sc := span.SpanContext()
+			entry.TraceID = sc.TraceID.String()
+			entry.SpanID = sc.SpanID.String()
+		}
+		c.logger.Log(entry)
+	}
+	return conn, err
 }
 
 func (c connector) Driver() driver.Driver {
@@ -133,4 +301,4 @@ func DefaultURLMux() *URLMux {
 // details on supported scheme(s) and URL parameter(s).
 func Open(ctx context.Context, urlstr string) (*sql.DB, error) {
 	return defaultURLMux.OpenMySQL(ctx, urlstr)
-}
\ No newline at end of file
+}
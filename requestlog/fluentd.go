@@ -0,0 +1,88 @@
+// Copyright 2019 The Go Cloud Development Kit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package requestlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// FluentdLogger writes Entries to w as Fluentd forward-protocol JSON
+// records, one per line: [tag, unix_time, {fields...}].
+type FluentdLogger struct {
+	tag   string
+	onErr func(error)
+
+	mu  sync.Mutex
+	w   io.Writer
+	buf bytes.Buffer
+	enc *json.Encoder
+}
+
+// NewFluentdLogger returns a Logger that writes NDJSON Fluentd forward
+// records to w, tagged with tag. onErr, if non-nil, is called with any
+// error encountered while marshaling or writing an entry; it must be safe
+// to call from multiple goroutines.
+func NewFluentdLogger(w io.Writer, tag string, onErr func(error)) *FluentdLogger {
+	l := &FluentdLogger{
+		tag:   tag,
+		onErr: onErr,
+		w:     w,
+	}
+	l.enc = json.NewEncoder(&l.buf)
+	return l
+}
+
+// Log implements Logger.Log.
+func (l *FluentdLogger) Log(e *Entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.buf.Reset()
+	record := []interface{}{l.tag, e.ReceivedTime.Unix(), fluentdFields(e)}
+	if err := l.enc.Encode(record); err != nil {
+		l.reportErr(err)
+		return
+	}
+	if _, err := l.w.Write(l.buf.Bytes()); err != nil {
+		l.reportErr(err)
+	}
+}
+
+func (l *FluentdLogger) reportErr(err error) {
+	if l.onErr != nil {
+		l.onErr(err)
+	}
+}
+
+func fluentdFields(e *Entry) map[string]interface{} {
+	fields := map[string]interface{}{
+		"operation":  e.Operation,
+		"resource":   e.Resource,
+		"latency_ms": float64(e.Latency) / float64(1e6),
+	}
+	if e.Error != nil {
+		fields["error"] = e.Error.Error()
+	}
+	if e.TraceID != "" {
+		fields["trace_id"] = e.TraceID
+	}
+	if e.SpanID != "" {
+		fields["span_id"] = e.SpanID
+	}
+	return fields
+}
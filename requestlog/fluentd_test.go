@@ -0,0 +1,118 @@
+// Copyright 2019 The Go Cloud Development Kit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package requestlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// errWriter is an io.Writer that always fails, for exercising onErr.
+type errWriter struct {
+	err error
+}
+
+func (w errWriter) Write(p []byte) (int, error) {
+	return 0, w.err
+}
+
+func TestFluentdLoggerShape(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewFluentdLogger(&buf, "myapp.access", nil)
+
+	recv := time.Unix(1500000000, 0)
+	l.Log(&Entry{
+		ReceivedTime: recv,
+		Operation:    "mysql.Connect",
+		Resource:     "db.example.com/mydb",
+		Latency:      250 * time.Millisecond,
+	})
+
+	var record []interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("unmarshaling record: %v", err)
+	}
+	if len(record) != 3 {
+		t.Fatalf("got %d elements, want [tag, unix_time, fields]", len(record))
+	}
+	if got, want := record[0], "myapp.access"; got != want {
+		t.Errorf("tag = %v, want %v", got, want)
+	}
+	if got, want := record[1], float64(recv.Unix()); got != want {
+		t.Errorf("unix_time = %v, want %v", got, want)
+	}
+	fields, ok := record[2].(map[string]interface{})
+	if !ok {
+		t.Fatalf("fields = %T, want map[string]interface{}", record[2])
+	}
+	if got, want := fields["operation"], "mysql.Connect"; got != want {
+		t.Errorf("operation = %v, want %v", got, want)
+	}
+	if got, want := fields["resource"], "db.example.com/mydb"; got != want {
+		t.Errorf("resource = %v, want %v", got, want)
+	}
+	if got, want := fields["latency_ms"], 250.0; got != want {
+		t.Errorf("latency_ms = %v, want %v", got, want)
+	}
+	for _, key := range []string{"error", "trace_id", "span_id"} {
+		if _, ok := fields[key]; ok {
+			t.Errorf("fields[%q] present, want omitted when empty", key)
+		}
+	}
+}
+
+func TestFluentdLoggerOptionalFields(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewFluentdLogger(&buf, "myapp.access", nil)
+
+	l.Log(&Entry{
+		Operation: "GetVariable",
+		Error:     errors.New("boom"),
+		TraceID:   "trace-1",
+		SpanID:    "span-1",
+	})
+
+	var record []interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("unmarshaling record: %v", err)
+	}
+	fields := record[2].(map[string]interface{})
+	if got, want := fields["error"], "boom"; got != want {
+		t.Errorf("error = %v, want %v", got, want)
+	}
+	if got, want := fields["trace_id"], "trace-1"; got != want {
+		t.Errorf("trace_id = %v, want %v", got, want)
+	}
+	if got, want := fields["span_id"], "span-1"; got != want {
+		t.Errorf("span_id = %v, want %v", got, want)
+	}
+}
+
+func TestFluentdLoggerReportsWriteError(t *testing.T) {
+	writeErr := errors.New("write failed")
+	var gotErr error
+	l := NewFluentdLogger(errWriter{writeErr}, "myapp.access", func(err error) {
+		gotErr = err
+	})
+
+	l.Log(&Entry{Operation: "mysql.Connect"})
+
+	if gotErr != writeErr {
+		t.Errorf("onErr called with %v, want %v", gotErr, writeErr)
+	}
+}
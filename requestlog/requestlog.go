@@ -0,0 +1,55 @@
+// Copyright 2019 The Go Cloud Development Kit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package requestlog provides a pluggable sink for structured request logs
+// emitted by go-cloud drivers, such as runtimevar's runtimeconfigurator
+// watcher and the mysql connector. It is meant for shipping access logs to
+// a log collector (Fluentd, Stackdriver) and is independent of the
+// OpenCensus stats pipeline those drivers already export.
+package requestlog
+
+import "time"
+
+// Logger receives a structured Entry for every request a driver makes, such
+// as an RPC or a database connection.
+//
+// Implementations must be safe for concurrent use.
+type Logger interface {
+	Log(*Entry)
+}
+
+// Entry describes a single request made by a go-cloud driver.
+type Entry struct {
+	// ReceivedTime is when the request was issued.
+	ReceivedTime time.Time
+
+	// Operation names the RPC method or query the request performed, e.g.
+	// "GetVariable" or "mysql.Connect".
+	Operation string
+
+	// Resource is the name of the resource the request acted on, such as a
+	// Runtime Configurator variable path or a MySQL DSN's host and database.
+	Resource string
+
+	// Latency is how long the request took.
+	Latency time.Duration
+
+	// Error is the error the request returned, if any.
+	Error error
+
+	// TraceID and SpanID identify the trace this request belongs to, if the
+	// driver is also instrumented with OpenCensus tracing.
+	TraceID string
+	SpanID  string
+}
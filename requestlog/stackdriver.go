@@ -0,0 +1,82 @@
+// Copyright 2019 The Go Cloud Development Kit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package requestlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// StackdriverLogger writes Entries to w as newline-delimited JSON log
+// entries in the shape the Stackdriver Logging agent parses: latency goes
+// under "httpRequest", the rest under "jsonPayload", and trace/span IDs
+// under the well-known "logging.googleapis.com/trace" and
+// "logging.googleapis.com/spanId" keys.
+type StackdriverLogger struct {
+	onErr func(error)
+
+	mu  sync.Mutex
+	w   io.Writer
+	enc *json.Encoder
+}
+
+// NewStackdriverLogger returns a Logger that writes NDJSON Stackdriver log
+// entries to w. onErr, if non-nil, is called with any error encountered
+// while marshaling or writing an entry; it must be safe to call from
+// multiple goroutines.
+func NewStackdriverLogger(w io.Writer, onErr func(error)) *StackdriverLogger {
+	return &StackdriverLogger{
+		onErr: onErr,
+		w:     w,
+		enc:   json.NewEncoder(w),
+	}
+}
+
+// Log implements Logger.Log.
+func (l *StackdriverLogger) Log(e *Entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	payload := map[string]interface{}{
+		"operation": e.Operation,
+		"resource":  e.Resource,
+	}
+	if e.Error != nil {
+		payload["error"] = e.Error.Error()
+	}
+	severity := "INFO"
+	if e.Error != nil {
+		severity = "ERROR"
+	}
+	entry := map[string]interface{}{
+		"severity": severity,
+		"time":     e.ReceivedTime.Format("2006-01-02T15:04:05.000000000Z07:00"),
+		"httpRequest": map[string]interface{}{
+			"latency": fmt.Sprintf("%.9fs", e.Latency.Seconds()),
+		},
+		"jsonPayload": payload,
+	}
+	if e.TraceID != "" {
+		entry["logging.googleapis.com/trace"] = e.TraceID
+	}
+	if e.SpanID != "" {
+		entry["logging.googleapis.com/spanId"] = e.SpanID
+	}
+	if err := l.enc.Encode(entry); err != nil && l.onErr != nil {
+		l.onErr(err)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2019 The Go Cloud Development Kit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package requestlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStackdriverLoggerShape(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewStackdriverLogger(&buf, nil)
+
+	recv := time.Date(2019, 3, 4, 5, 6, 7, 0, time.UTC)
+	l.Log(&Entry{
+		ReceivedTime: recv,
+		Operation:    "mysql.Connect",
+		Resource:     "db.example.com/mydb",
+		Latency:      1500 * time.Millisecond,
+	})
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("unmarshaling entry: %v", err)
+	}
+	if got, want := entry["severity"], "INFO"; got != want {
+		t.Errorf("severity = %v, want %v", got, want)
+	}
+	httpRequest, ok := entry["httpRequest"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("httpRequest = %T, want map[string]interface{}", entry["httpRequest"])
+	}
+	if got, want := httpRequest["latency"], "1.500000000s"; got != want {
+		t.Errorf("latency = %v, want %v", got, want)
+	}
+	payload, ok := entry["jsonPayload"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("jsonPayload = %T, want map[string]interface{}", entry["jsonPayload"])
+	}
+	if got, want := payload["operation"], "mysql.Connect"; got != want {
+		t.Errorf("operation = %v, want %v", got, want)
+	}
+	if got, want := payload["resource"], "db.example.com/mydb"; got != want {
+		t.Errorf("resource = %v, want %v", got, want)
+	}
+	for _, key := range []string{"logging.googleapis.com/trace", "logging.googleapis.com/spanId"} {
+		if _, ok := entry[key]; ok {
+			t.Errorf("entry[%q] present, want omitted when empty", key)
+		}
+	}
+	if _, ok := payload["error"]; ok {
+		t.Errorf("jsonPayload[error] present, want omitted when nil")
+	}
+}
+
+func TestStackdriverLoggerErrorSeverityAndOptionalFields(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewStackdriverLogger(&buf, nil)
+
+	l.Log(&Entry{
+		Operation: "GetVariable",
+		Error:     errors.New("boom"),
+		TraceID:   "trace-1",
+		SpanID:    "span-1",
+	})
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("unmarshaling entry: %v", err)
+	}
+	if got, want := entry["severity"], "ERROR"; got != want {
+		t.Errorf("severity = %v, want %v", got, want)
+	}
+	payload := entry["jsonPayload"].(map[string]interface{})
+	if got, want := payload["error"], "boom"; got != want {
+		t.Errorf("jsonPayload[error] = %v, want %v", got, want)
+	}
+	if got, want := entry["logging.googleapis.com/trace"], "trace-1"; got != want {
+		t.Errorf("trace = %v, want %v", got, want)
+	}
+	if got, want := entry["logging.googleapis.com/spanId"], "span-1"; got != want {
+		t.Errorf("spanId = %v, want %v", got, want)
+	}
+}
+
+func TestStackdriverLoggerReportsWriteError(t *testing.T) {
+	writeErr := errors.New("write failed")
+	var gotErr error
+	l := NewStackdriverLogger(errWriter{writeErr}, func(err error) {
+		gotErr = err
+	})
+
+	l.Log(&Entry{Operation: "mysql.Connect"})
+
+	if gotErr != writeErr {
+		t.Errorf("onErr called with %v, want %v", gotErr, writeErr)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 The Go Cloud Development Kit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package consulvar provides a runtimevar driver implementation to read
+// configurations from Consul's KV store and watch for updates using
+// Consul's blocking queries (the X-Consul-Index mechanism), so changes are
+// delivered without fixed-interval polling.
+//
+// User constructs a *consulapi.Client, then uses it to construct any
+// number of runtimevar.Variable objects via NewVariable.
+package consulvar
+
+import (
+	"context"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+
+	"github.com/google/go-cloud/runtimevar"
+	"github.com/google/go-cloud/runtimevar/driver"
+)
+
+// NewVariable constructs a runtimevar.Variable object with this package as the driver
+// implementation. Provide targetType for Config to unmarshal updated configurations into similar
+// objects during the Watch call.
+func NewVariable(client *consulapi.Client, key string, targetType interface{}, opts *WatchOptions) (*runtimevar.Variable, error) {
+	if opts == nil {
+		opts = &WatchOptions{}
+	}
+	decodeFn := runtimevar.JSONDecode
+	if opts.Decode != nil {
+		decodeFn = opts.Decode
+	}
+	decoder := runtimevar.NewDecoder(targetType, decodeFn)
+
+	return runtimevar.New(&watcher{
+		kv:      client.KV(),
+		key:     key,
+		decoder: decoder,
+		state:   &driver.State{},
+	}), nil
+}
+
+// WatchOptions provide optional configurations to the Watcher.
+type WatchOptions struct {
+	// Decode is the function to decode the configuration storage value into the specified type. If
+	// this is not set, it defaults to JSON unmarshal.
+	Decode runtimevar.Decode
+}
+
+// watcher implements driver.Watcher for configurations provided by Consul's KV store.
+type watcher struct {
+	kv      *consulapi.KV
+	key     string
+	decoder *runtimevar.Decoder
+
+	state     *driver.State
+	lastIndex uint64
+}
+
+// Close implements driver.Watcher.Close. This is a no-op for this driver.
+func (w *watcher) Close() error {
+	return nil
+}
+
+// Watch blocks until the key changes, the Context's Done channel closes or an error occurs. It
+// implements the driver.Watcher.Watch method using the shared driver.PollLoop helper: fetch
+// issues a Consul blocking query, which only returns once lastIndex is stale.
+func (w *watcher) Watch(ctx context.Context) (driver.Variable, error) {
+	v, state, err := driver.PollLoop(ctx, w.state, w.fetch, w.decoder.Decode)
+	w.state = state
+	return v, err
+}
+
+// fetch implements driver.FetchFunc. It issues a Consul blocking query keyed off lastIndex, so
+// the call does not return until the key changes (or the server's own long-poll deadline, a few
+// minutes, elapses with no change, in which case fetch is simply called again).
+func (w *watcher) fetch(ctx context.Context) ([]byte, time.Time, error) {
+	pair, meta, err := w.kv.Get(w.key, (&consulapi.QueryOptions{
+		WaitIndex: w.lastIndex,
+	}).WithContext(ctx))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	w.lastIndex = meta.LastIndex
+	if pair == nil {
+		return nil, time.Time{}, driver.ErrNotFound
+	}
+	return pair.Value, time.Now(), nil
+}
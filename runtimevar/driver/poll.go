@@ -0,0 +1,88 @@
+// Copyright 2019 The Go Cloud Development Kit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound should be returned by a FetchFunc when the variable it fetches
+// does not currently exist. A FetchFunc backed by a service that has its own
+// distinct not-found error (e.g. a gRPC NotFound status) should return that
+// error wrapped so that errors.Is(err, ErrNotFound) reports true; PollLoop
+// returns the error exactly as fetch returned it, so callers that inspect
+// the concrete backend error still see it.
+var ErrNotFound = errors.New("runtimevar/driver: variable not found")
+
+// FetchFunc retrieves the current raw value of a variable. A FetchFunc is
+// responsible for blocking an appropriate amount of time between retries of
+// its own backend (a fixed interval, a long-poll RPC, a native blocking
+// watch, ...); PollLoop calls it in a tight loop and relies on it not to
+// busy-loop. It returns ErrNotFound if the variable does not currently
+// exist.
+type FetchFunc func(ctx context.Context) (data []byte, updateTime time.Time, err error)
+
+// DecodeFunc decodes the raw bytes retrieved by a FetchFunc into the
+// application-level value a Variable should carry.
+type DecodeFunc func(data []byte) (interface{}, error)
+
+// State holds what PollLoop needs to remember between calls in order to
+// detect changes. The zero value is ready to use for an initial call to
+// PollLoop.
+type State struct {
+	Data      []byte
+	IsDeleted bool
+}
+
+// PollLoop implements the fetch/diff/decode loop common to Watcher
+// implementations: it calls fetch repeatedly until the data it returns
+// differs from state (or the variable's deleted/undeleted status changes),
+// decodes the new data, and returns the resulting Variable along with the
+// State to pass to the next call to PollLoop.
+//
+// PollLoop returns as soon as fetch returns a non-nil error, translating a
+// first-time ErrNotFound into a State marked as deleted so that later calls
+// don't keep re-reporting the same deletion: an ErrNotFound for a State that
+// is already marked as deleted is not returned, and PollLoop keeps calling
+// fetch until the variable reappears or ctx is done.
+func PollLoop(ctx context.Context, state *State, fetch FetchFunc, decode DecodeFunc) (Variable, *State, error) {
+	if state == nil {
+		state = &State{}
+	}
+	for {
+		data, updateTime, err := fetch(ctx)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			if state.IsDeleted {
+				break
+			}
+			return Variable{}, &State{IsDeleted: true}, err
+		case err != nil:
+			return Variable{}, state, err
+		case state.IsDeleted || !bytes.Equal(state.Data, data):
+			val, err := decode(data)
+			if err != nil {
+				return Variable{}, state, err
+			}
+			return Variable{Value: val, UpdateTime: updateTime}, &State{Data: data}, nil
+		}
+		if ctx.Err() != nil {
+			return Variable{}, state, ctx.Err()
+		}
+	}
+}
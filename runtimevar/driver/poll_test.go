@@ -0,0 +1,116 @@
+// Copyright 2019 The Go Cloud Development Kit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPollLoop(t *testing.T) {
+	ctx := context.Background()
+	calls := 0
+	fetch := func(ctx context.Context) ([]byte, time.Time, error) {
+		calls++
+		switch calls {
+		case 1, 2:
+			return []byte("v1"), time.Unix(1, 0), nil
+		case 3:
+			return []byte("v2"), time.Unix(2, 0), nil
+		default:
+			return nil, time.Time{}, ErrNotFound
+		}
+	}
+	decode := func(data []byte) (interface{}, error) {
+		return string(data), nil
+	}
+
+	v, state, err := PollLoop(ctx, nil, fetch, decode)
+	if err != nil {
+		t.Fatalf("first PollLoop: %v", err)
+	}
+	if v.Value != "v1" {
+		t.Errorf("got value %v, want v1", v.Value)
+	}
+	if calls != 1 {
+		t.Errorf("got %d fetch calls, want 1", calls)
+	}
+
+	// fetch returns the same data once more (call 2) before changing (call
+	// 3), so PollLoop must keep polling until the data actually differs.
+	v, state, err = PollLoop(ctx, state, fetch, decode)
+	if err != nil {
+		t.Fatalf("second PollLoop: %v", err)
+	}
+	if v.Value != "v2" {
+		t.Errorf("got value %v, want v2", v.Value)
+	}
+	if calls != 3 {
+		t.Errorf("got %d fetch calls, want 3", calls)
+	}
+
+	// The variable is now deleted.
+	_, state, err = PollLoop(ctx, state, fetch, decode)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got err %v, want ErrNotFound", err)
+	}
+	if !state.IsDeleted {
+		t.Errorf("state.IsDeleted = false, want true")
+	}
+
+	// The deletion is already known, so PollLoop must not keep reporting it
+	// forever: it should keep calling fetch (which still unconditionally
+	// returns ErrNotFound) until the context is done.
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, _, err = PollLoop(cctx, state, fetch, decode)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got err %v, want context.DeadlineExceeded", err)
+	}
+}
+
+// notFoundError is a FetchFunc error type that wraps a backend's own
+// not-found error, the way runtimeconfigurator's fetch does with a gRPC
+// NotFound status.
+type notFoundError struct {
+	err error
+}
+
+func (e notFoundError) Error() string        { return e.err.Error() }
+func (e notFoundError) Unwrap() error        { return e.err }
+func (e notFoundError) Is(target error) bool { return target == ErrNotFound }
+
+func TestPollLoopPreservesWrappedNotFoundError(t *testing.T) {
+	backendErr := errors.New("backend: variable foo not found")
+	fetch := func(ctx context.Context) ([]byte, time.Time, error) {
+		return nil, time.Time{}, notFoundError{backendErr}
+	}
+	decode := func(data []byte) (interface{}, error) {
+		return nil, nil
+	}
+
+	_, state, err := PollLoop(context.Background(), nil, fetch, decode)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got err %v, want it to satisfy errors.Is(err, ErrNotFound)", err)
+	}
+	if err.Error() != backendErr.Error() {
+		t.Errorf("got error message %q, want the original backend error %q preserved", err.Error(), backendErr.Error())
+	}
+	if !state.IsDeleted {
+		t.Errorf("state.IsDeleted = false, want true")
+	}
+}
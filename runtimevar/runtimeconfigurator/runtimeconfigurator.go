@@ -20,13 +20,17 @@
 package runtimeconfigurator
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/golang/protobuf/ptypes"
+	"github.com/google/go-cloud/requestlog"
 	"github.com/google/go-cloud/runtimevar"
 	"github.com/google/go-cloud/runtimevar/driver"
+	"go.opencensus.io/trace"
 	"google.golang.org/api/option"
 	transport "google.golang.org/api/transport/grpc"
 	pb "google.golang.org/genproto/googleapis/cloud/runtimeconfig/v1beta1"
@@ -40,6 +44,11 @@ const (
 	endPoint = "runtimevar.googleapis.com:443"
 	// defaultWaitTimeout is the default value for WatchOptions.WaitTime if not set.
 	defaultWaitTimeout = 10 * time.Minute
+	// defaultMaxWait is the default value for WatchOptions.MaxWait if not set.
+	defaultMaxWait = 10 * time.Minute
+	// maxRetryBackoff bounds the exponential backoff applied between
+	// WaitForChange retries after a transient error.
+	maxRetryBackoff = 30 * time.Second
 )
 
 // List of authentication scopes required for using the Runtime Configurator API.
@@ -89,6 +98,14 @@ func (c *Client) NewVariable(ctx context.Context, name ResourceName, targetType
 		return nil, fmt.Errorf("cannot have negative WaitTime option value: %v", waitTime)
 	}
 
+	maxWait := opts.MaxWait
+	switch {
+	case maxWait == 0:
+		maxWait = defaultMaxWait
+	case maxWait < 0:
+		return nil, fmt.Errorf("cannot have negative MaxWait option value: %v", maxWait)
+	}
+
 	decodeFn := runtimevar.JSONDecode
 	if opts.Decode != nil {
 		decodeFn = opts.Decode
@@ -99,8 +116,12 @@ func (c *Client) NewVariable(ctx context.Context, name ResourceName, targetType
 		client:      c.client,
 		waitTime:    waitTime,
 		lastRPCTime: time.Now().Add(-1 * waitTime), // Remove wait on first Watch call.
+		useLongPoll: opts.UseLongPoll,
+		maxWait:     maxWait,
 		name:        name.String(),
 		decoder:     decoder,
+		logger:      opts.Logger,
+		state:       &driver.State{},
 	}), nil
 }
 
@@ -129,6 +150,25 @@ type WatchOptions struct {
 	// Decode is the function to decode the configuration storage value into the specified type. If
 	// this is not set, it defaults to JSON unmarshal.
 	Decode runtimevar.Decode
+
+	// UseLongPoll switches Watch from fixed-interval polling via GetVariable to a
+	// long-poll implementation driven by the WaitForChange RPC: Watch blocks
+	// server-side until the variable changes (or MaxWait elapses) instead of
+	// sleeping for WaitTime between RPCs. This reduces both latency and the
+	// rate at which the API quota is consumed.
+	UseLongPoll bool
+
+	// MaxWait bounds how long a single WaitForChange RPC may block the server
+	// before Watch re-issues it. It is only consulted when UseLongPoll is
+	// true.
+	//
+	// If this option is not set or set to 0, it uses defaultMaxWait value.
+	MaxWait time.Duration
+
+	// Logger, if set, receives a requestlog.Entry for every GetVariable and
+	// WaitForChange RPC this watcher makes, so operators can ship access
+	// logs without redefining the OpenCensus stats pipeline.
+	Logger requestlog.Logger
 }
 
 // watcher implements driver.Watcher for configurations provided by the Runtime Configurator
@@ -137,13 +177,37 @@ type watcher struct {
 	client      pb.RuntimeConfigManagerClient
 	waitTime    time.Duration
 	lastRPCTime time.Time
+	useLongPoll bool
+	maxWait     time.Duration
 	name        string
 	decoder     *runtimevar.Decoder
-	bytes       []byte
-	isDeleted   bool
+	logger      requestlog.Logger
+	state       *driver.State
 	updateTime  time.Time
 }
 
+// logRequest records a requestlog.Entry for an RPC this watcher made, if a Logger is configured.
+// It pulls the trace and span IDs off ctx when the call is covered by an
+// OpenCensus trace span, so log entries can be correlated with traces.
+func (w *watcher) logRequest(ctx context.Context, operation string, start time.Time, err error) {
+	if w.logger == nil {
+		return
+	}
+	entry := &requestlog.Entry{
+		ReceivedTime: start,
+		Operation:    operation,
+		Resource:     w.name,
+		Latency:      time.Since(start),
+		Error:        err,
+	}
+	if span := trace.FromContext(ctx); span != nil {
+		sc := span.SpanContext()
+		entry.TraceID = sc.TraceID.String()
+		entry.SpanID = sc.SpanID.String()
+	}
+	w.logger.Log(entry)
+}
+
 // Close implements driver.Watcher.Close.  This is a no-op for this driver.
 func (w *watcher) Close() error {
 	return nil
@@ -152,79 +216,184 @@ func (w *watcher) Close() error {
 // Watch blocks until the file changes, the Context's Done channel closes or an error occurs. It
 // implements the driver.Watcher.Watch method.
 func (w *watcher) Watch(ctx context.Context) (driver.Variable, error) {
+	if w.useLongPoll {
+		return w.watchLongPoll(ctx)
+	}
+	return w.watchPoll(ctx)
+}
+
+// watchLongPoll implements Watch using the WaitForChange RPC: it blocks server-side until the
+// variable changes (bounded by w.maxWait) rather than polling GetVariable at a fixed interval.
+func (w *watcher) watchLongPoll(ctx context.Context) (driver.Variable, error) {
 	zeroVar := driver.Variable{}
+	backoff := time.Second
+	// Until a value or a deletion has been reported, skip WaitForChange (which
+	// blocks until the next change) and fetch the current value right away.
+	waitForChange := !w.updateTime.IsZero()
 
-	// Loop to check for changes or continue waiting.
 	for {
-		// Block until waitTime or context cancelled/timed out.
-		t := time.NewTimer(w.waitTime - time.Now().Sub(w.lastRPCTime))
-		select {
-		case <-t.C:
-		case <-ctx.Done():
-			t.Stop()
-			return zeroVar, ctx.Err()
+		var err error
+		if waitForChange {
+			waitCtx, cancel := context.WithTimeout(ctx, w.maxWait)
+			start := time.Now()
+			_, err = w.client.WaitForChange(waitCtx, &pb.WaitForChangeRequest{Name: w.name})
+			cancel()
+			w.logRequest(ctx, "WaitForChange", start, err)
 		}
+		waitForChange = true
 
-		// Use GetVariables RPC and check for deltas based on the response.
-		vpb, err := w.client.GetVariable(ctx, &pb.GetVariableRequest{Name: w.name})
-		w.lastRPCTime = time.Now()
-		if err == nil {
-			updateTime, err := parseUpdateTime(vpb)
-			if err != nil {
-				return zeroVar, err
+		if err != nil {
+			if ctx.Err() != nil {
+				return zeroVar, ctx.Err()
 			}
-
-			// Determine if there are any changes based on the bytes. If there are, update cache and
-			// return nil, else continue on.
-			bytes := bytesFromProto(vpb)
-			if w.isDeleted || bytesNotEqual(w.bytes, bytes) {
-				w.bytes = bytes
-				w.updateTime = updateTime
-				w.isDeleted = false
-				val, err := w.decoder.Decode(bytes)
-				if err != nil {
+			st, ok := status.FromError(err)
+			switch {
+			case ok && st.Code() == codes.DeadlineExceeded:
+				// No change within MaxWait: loop again.
+				backoff = time.Second
+				continue
+			case ok && st.Code() == codes.NotFound:
+				if !w.state.IsDeleted {
+					w.state = &driver.State{IsDeleted: true}
+					w.updateTime = time.Now().UTC()
+					return zeroVar, err
+				}
+				// The variable is still deleted: a server that fails WaitForChange
+				// fast for a nonexistent resource would otherwise make this
+				// busy-loop, so back off between retries just like the transient
+				// error case below.
+				if err := sleepBackoff(ctx, backoff); err != nil {
+					return zeroVar, err
+				}
+				if backoff *= 2; backoff > maxRetryBackoff {
+					backoff = maxRetryBackoff
+				}
+				continue
+			default:
+				// Transient error (e.g. Unavailable): back off and retry.
+				if err := sleepBackoff(ctx, backoff); err != nil {
 					return zeroVar, err
 				}
-				return driver.Variable{
-					Value:      val,
-					UpdateTime: updateTime,
-				}, nil
+				if backoff *= 2; backoff > maxRetryBackoff {
+					backoff = maxRetryBackoff
+				}
+				continue
 			}
+		}
 
-		} else {
-			if st, ok := status.FromError(err); !ok || st.Code() != codes.NotFound {
-				return zeroVar, err
-			}
-			// For RPC not found error, if last known state is not deleted, mark isDeleted and
-			// return error, else treat as no change has occurred.
-			if !w.isDeleted {
-				w.isDeleted = true
-				w.updateTime = time.Now().UTC()
-				return zeroVar, err
+		// WaitForChange returned without error: the variable changed (or this is the
+		// first call). Re-fetch the current value and diff it against the cache.
+		getStart := time.Now()
+		vpb, err := w.client.GetVariable(ctx, &pb.GetVariableRequest{Name: w.name})
+		w.logRequest(ctx, "GetVariable", getStart, err)
+		if err != nil {
+			if st, ok := status.FromError(err); ok && st.Code() == codes.NotFound {
+				if !w.state.IsDeleted {
+					w.state = &driver.State{IsDeleted: true}
+					w.updateTime = time.Now().UTC()
+					return zeroVar, err
+				}
+				continue
 			}
+			return zeroVar, err
 		}
+
+		updateTime, err := parseUpdateTime(vpb)
+		if err != nil {
+			return zeroVar, err
+		}
+		data := bytesFromProto(vpb)
+		if !w.state.IsDeleted && bytes.Equal(w.state.Data, data) {
+			// No actual content change (e.g. metadata-only update); keep waiting.
+			continue
+		}
+		w.state = &driver.State{Data: data}
+		w.updateTime = updateTime
+		val, err := w.decoder.Decode(data)
+		if err != nil {
+			return zeroVar, err
+		}
+		return driver.Variable{
+			Value:      val,
+			UpdateTime: updateTime,
+		}, nil
 	}
 }
 
-func bytesFromProto(vpb *pb.Variable) []byte {
-	// Proto may contain either bytes or text.  If it contains text content, convert that to []byte.
-	if _, isBytes := vpb.GetContents().(*pb.Variable_Value); isBytes {
-		return vpb.GetValue()
+// sleepBackoff blocks for backoff, or until ctx is done, whichever comes first.
+func sleepBackoff(ctx context.Context, backoff time.Duration) error {
+	t := time.NewTimer(backoff)
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		t.Stop()
+		return ctx.Err()
 	}
-	return []byte(vpb.GetText())
 }
 
-func bytesNotEqual(a []byte, b []byte) bool {
-	n := len(a)
-	if n != len(b) {
-		return true
+// watchPoll implements Watch by polling GetVariable every w.waitTime, using the shared
+// driver.PollLoop helper to detect and decode changes.
+func (w *watcher) watchPoll(ctx context.Context) (driver.Variable, error) {
+	v, state, err := driver.PollLoop(ctx, w.state, w.fetch, w.decoder.Decode)
+	w.state = state
+	if errors.Is(err, driver.ErrNotFound) {
+		w.updateTime = time.Now().UTC()
 	}
-	for i := 0; i < n; i++ {
-		if a[i] != b[i] {
-			return true
+	return v, err
+}
+
+// fetch blocks until w.waitTime has elapsed since the last RPC, then issues a GetVariable RPC
+// and returns the variable's current raw value. It implements driver.FetchFunc.
+func (w *watcher) fetch(ctx context.Context) ([]byte, time.Time, error) {
+	t := time.NewTimer(w.waitTime - time.Now().Sub(w.lastRPCTime))
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+		t.Stop()
+		return nil, time.Time{}, ctx.Err()
+	}
+
+	start := time.Now()
+	vpb, err := w.client.GetVariable(ctx, &pb.GetVariableRequest{Name: w.name})
+	w.lastRPCTime = time.Now()
+	w.logRequest(ctx, "GetVariable", start, err)
+	if err != nil {
+		if st, ok := status.FromError(err); ok && st.Code() == codes.NotFound {
+			// Wrap, rather than replace, the gRPC status error: PollLoop only
+			// needs errors.Is(err, driver.ErrNotFound) to be true to detect the
+			// deletion, but callers inspecting the concrete error (e.g. via
+			// status.FromError) must keep seeing the real NotFound status, as
+			// they did before this driver used PollLoop.
+			return nil, time.Time{}, notFoundError{err}
 		}
+		return nil, time.Time{}, err
 	}
-	return false
+	updateTime, err := parseUpdateTime(vpb)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return bytesFromProto(vpb), updateTime, nil
+}
+
+// notFoundError wraps the GetVariable RPC's concrete NotFound status error
+// so that driver.PollLoop recognizes it as driver.ErrNotFound via errors.Is,
+// while preserving the original error (and, via Unwrap, its gRPC status) for
+// callers that inspect it directly.
+type notFoundError struct {
+	err error
+}
+
+func (e notFoundError) Error() string { return e.err.Error() }
+func (e notFoundError) Unwrap() error { return e.err }
+func (e notFoundError) Is(target error) bool { return target == driver.ErrNotFound }
+
+func bytesFromProto(vpb *pb.Variable) []byte {
+	// Proto may contain either bytes or text.  If it contains text content, convert that to []byte.
+	if _, isBytes := vpb.GetContents().(*pb.Variable_Value); isBytes {
+		return vpb.GetValue()
+	}
+	return []byte(vpb.GetText())
 }
 
 func parseUpdateTime(vpb *pb.Variable) (time.Time, error) {
@@ -234,4 +403,4 @@ func parseUpdateTime(vpb *pb.Variable) (time.Time, error) {
 			"variable message for name=%q contains invalid timestamp: %v", vpb.Name, err)
 	}
 	return updateTime, nil
-}
\ No newline at end of file
+}